@@ -0,0 +1,137 @@
+// Copyright 2016 The Linux Foundation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reference
+
+import (
+	// Register the digest algorithms an image reference is allowed to use,
+	// so digest.Digest.Validate can verify them.
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"fmt"
+	"strings"
+
+	digest "github.com/opencontainers/go-digest"
+)
+
+// defaultDomain and officialRepoName mirror Docker's historical handling
+// of short names: "alpine" is familiar shorthand for
+// "docker.io/library/alpine".
+const (
+	defaultDomain    = "docker.io"
+	officialRepoName = "library"
+)
+
+// Reference is a parsed image reference. Domain is empty unless the input
+// names an explicit registry host; Path is always populated; Tag and Digest
+// are empty when not present in the input.
+type Reference struct {
+	Domain string
+	Path   string
+	Tag    string
+	Digest digest.Digest
+}
+
+// Parse parses s in any of the forms real tools accept: name, name:tag,
+// name@digest, or name:tag@digest.
+func Parse(s string) (*Reference, error) {
+	if s == "" {
+		return nil, fmt.Errorf("reference: %q is not a valid reference", s)
+	}
+
+	named := s
+	var dgst digest.Digest
+	if i := strings.Index(s, "@"); i != -1 {
+		named = s[:i]
+		dgst = digest.Digest(s[i+1:])
+		if err := dgst.Validate(); err != nil {
+			return nil, fmt.Errorf("reference: invalid digest in %q: %w", s, err)
+		}
+	}
+	if named == "" {
+		return nil, fmt.Errorf("reference: %q is not a valid reference", s)
+	}
+
+	domain, remainder := splitDomain(named)
+
+	path, tag := remainder, ""
+	if i := strings.LastIndex(remainder, ":"); i != -1 {
+		// A colon before the last "/" belongs to a domain's port, not a tag.
+		if j := strings.LastIndex(remainder, "/"); j < i {
+			path, tag = remainder[:i], remainder[i+1:]
+		}
+	}
+	if path == "" {
+		return nil, fmt.Errorf("reference: %q is not a valid reference", s)
+	}
+
+	return &Reference{
+		Domain: domain,
+		Path:   path,
+		Tag:    tag,
+		Digest: dgst,
+	}, nil
+}
+
+// splitDomain separates a leading registry domain from the remainder of a
+// reference's name, using the same heuristic as Docker Distribution: the
+// first component is a domain only if it contains a "." or ":", or is
+// exactly "localhost".
+func splitDomain(named string) (domain, remainder string) {
+	i := strings.Index(named, "/")
+	if i == -1 {
+		return "", named
+	}
+
+	first := named[:i]
+	if first == "localhost" || strings.ContainsAny(first, ".:") {
+		return first, named[i+1:]
+	}
+	return "", named
+}
+
+// String returns the canonical, fully-qualified form of r: domain/path,
+// followed by :tag and/or @digest when present. Parsing String's output
+// always yields an equal Reference.
+func (r *Reference) String() string {
+	var b strings.Builder
+	if r.Domain != "" {
+		b.WriteString(r.Domain)
+		b.WriteString("/")
+	}
+	b.WriteString(r.Path)
+	if r.Tag != "" {
+		b.WriteString(":")
+		b.WriteString(r.Tag)
+	}
+	if r.Digest != "" {
+		b.WriteString("@")
+		b.WriteString(r.Digest.String())
+	}
+	return b.String()
+}
+
+// Familiar returns the shorthand form a user would type: the domain is
+// dropped when it is the default docker.io registry, and the
+// "library/" prefix is dropped from official docker.io images.
+func (r *Reference) Familiar() string {
+	domain, path := r.Domain, r.Path
+	if domain == defaultDomain {
+		domain = ""
+		path = strings.TrimPrefix(path, officialRepoName+"/")
+	}
+
+	familiar := &Reference{Domain: domain, Path: path, Tag: r.Tag, Digest: r.Digest}
+	return familiar.String()
+}
@@ -0,0 +1,132 @@
+// Copyright 2016 The Linux Foundation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reference_test
+
+import (
+	"testing"
+
+	"github.com/opencontainers/image-spec/reference"
+)
+
+const testDigest = "sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+func TestParse(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		ref        string
+		wantDomain string
+		wantPath   string
+		wantTag    string
+		wantDigest string
+		wantErr    bool
+	}{
+		{
+			name:     "bare name",
+			ref:      "alpine",
+			wantPath: "alpine",
+		},
+		{
+			name:     "name and tag",
+			ref:      "alpine:3.18",
+			wantPath: "alpine",
+			wantTag:  "3.18",
+		},
+		{
+			name:       "name and digest",
+			ref:        "alpine@" + testDigest,
+			wantPath:   "alpine",
+			wantDigest: testDigest,
+		},
+		{
+			name:       "domain, name, tag and digest",
+			ref:        "docker.io/library/alpine:3.18@" + testDigest,
+			wantDomain: "docker.io",
+			wantPath:   "library/alpine",
+			wantTag:    "3.18",
+			wantDigest: testDigest,
+		},
+		{
+			name:       "domain with port",
+			ref:        "localhost:5000/myimage:latest",
+			wantDomain: "localhost:5000",
+			wantPath:   "myimage",
+			wantTag:    "latest",
+		},
+		{
+			name:    "invalid digest",
+			ref:     "alpine@sha256:not-a-digest",
+			wantErr: true,
+		},
+		{
+			name:    "empty",
+			ref:     "",
+			wantErr: true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := reference.Parse(tt.ref)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error parsing %q, got nil", tt.ref)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error parsing %q: %v", tt.ref, err)
+			}
+			if got.Domain != tt.wantDomain {
+				t.Errorf("Domain = %q, want %q", got.Domain, tt.wantDomain)
+			}
+			if got.Path != tt.wantPath {
+				t.Errorf("Path = %q, want %q", got.Path, tt.wantPath)
+			}
+			if got.Tag != tt.wantTag {
+				t.Errorf("Tag = %q, want %q", got.Tag, tt.wantTag)
+			}
+			if got.Digest.String() != tt.wantDigest {
+				t.Errorf("Digest = %q, want %q", got.Digest.String(), tt.wantDigest)
+			}
+		})
+	}
+}
+
+func TestFamiliar(t *testing.T) {
+	ref, err := reference.Parse("docker.io/library/alpine:3.18")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := ref.Familiar(), "alpine:3.18"; got != want {
+		t.Errorf("Familiar() = %q, want %q", got, want)
+	}
+}
+
+func TestStringRoundTrip(t *testing.T) {
+	const in = "docker.io/library/alpine:3.18@" + testDigest
+	ref, err := reference.Parse(in)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := ref.String(); got != in {
+		t.Errorf("String() = %q, want %q", got, in)
+	}
+
+	roundTripped, err := reference.Parse(ref.String())
+	if err != nil {
+		t.Fatalf("unexpected error re-parsing %q: %v", ref.String(), err)
+	}
+	if *roundTripped != *ref {
+		t.Errorf("round trip mismatch: got %+v, want %+v", roundTripped, ref)
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright 2016 The Linux Foundation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/opencontainers/image-spec/reference"
+)
+
+// authChallenge is a parsed Bearer www-authenticate header, as returned by
+// a registry's 401 response: RFC 6750 plus the realm/service/scope
+// parameters the OCI Distribution spec layers on top of it.
+type authChallenge struct {
+	realm   string
+	service string
+	scope   string
+}
+
+// authChallengeError carries an authChallenge up through Client.get so
+// fetchManifest can retry the request with a bearer token.
+type authChallengeError struct {
+	challenge *authChallenge
+}
+
+func (e *authChallengeError) Error() string {
+	return fmt.Sprintf("registry: authentication required (realm=%s)", e.challenge.realm)
+}
+
+// asAuthChallenge reports whether err is an *authChallengeError, and if so
+// sets *out to its challenge.
+func asAuthChallenge(err error, out **authChallenge) bool {
+	var challengeErr *authChallengeError
+	if !errors.As(err, &challengeErr) {
+		return false
+	}
+	*out = challengeErr.challenge
+	return true
+}
+
+// parseAuthChallenge parses a "Bearer realm=\"...\",service=\"...\",scope=\"...\""
+// WWW-Authenticate header value.
+func parseAuthChallenge(header string) (*authChallenge, error) {
+	const prefix = "Bearer "
+	if !strings.HasPrefix(header, prefix) {
+		return nil, fmt.Errorf("unsupported authentication scheme in %q", header)
+	}
+
+	c := &authChallenge{}
+	for _, part := range strings.Split(strings.TrimPrefix(header, prefix), ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		key, value := kv[0], strings.Trim(kv[1], `"`)
+		switch key {
+		case "realm":
+			c.realm = value
+		case "service":
+			c.service = value
+		case "scope":
+			c.scope = value
+		}
+	}
+	if c.realm == "" {
+		return nil, fmt.Errorf("missing realm in %q", header)
+	}
+	return c, nil
+}
+
+// authenticate exchanges an auth challenge for a bearer token, scoping the
+// request to ref's repository when the challenge didn't already supply a
+// scope.
+func (c *Client) authenticate(ctx context.Context, challenge *authChallenge, ref *reference.Reference) (string, error) {
+	u, err := url.Parse(challenge.realm)
+	if err != nil {
+		return "", fmt.Errorf("parsing realm %q: %w", challenge.realm, err)
+	}
+
+	q := u.Query()
+	if challenge.service != "" {
+		q.Set("service", challenge.service)
+	}
+	scope := challenge.scope
+	if scope == "" {
+		scope = fmt.Sprintf("repository:%s:pull", ref.Path)
+	}
+	q.Set("scope", scope)
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", fmt.Errorf("building token request: %w", err)
+	}
+
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return "", fmt.Errorf("requesting token from %s: %w", u, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token endpoint %s returned unexpected status %s", u, resp.Status)
+	}
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("reading token response: %w", err)
+	}
+
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.Unmarshal(raw, &body); err != nil {
+		return "", fmt.Errorf("decoding token response: %w", err)
+	}
+	if body.Token != "" {
+		return body.Token, nil
+	}
+	if body.AccessToken != "" {
+		return body.AccessToken, nil
+	}
+	return "", fmt.Errorf("token endpoint %s returned no token", u)
+}
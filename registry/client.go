@@ -0,0 +1,248 @@
+// Copyright 2016 The Linux Foundation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	digest "github.com/opencontainers/go-digest"
+	specs "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"github.com/opencontainers/image-spec/reference"
+	"github.com/opencontainers/image-spec/schema"
+	"github.com/opencontainers/image-spec/schema/convert"
+)
+
+// acceptMediaTypes is sent, in this order, as the Accept header of every
+// manifest request: every shape the rest of this module knows how to
+// validate, Docker and OCI alike, so a registry serving either is free to
+// answer with whichever it actually stores.
+var acceptMediaTypes = []string{
+	convert.OCIMediaTypeIndex,
+	convert.OCIMediaTypeManifest,
+	convert.DockerMediaTypeManifestList,
+	convert.DockerMediaTypeManifest,
+	convert.DockerMediaTypeSchema1Manifest,
+}
+
+// Client fetches manifests from an OCI Distribution v2 registry.
+type Client struct {
+	// HTTPClient is used for every request. It defaults to
+	// http.DefaultClient when nil.
+	HTTPClient *http.Client
+
+	// Scheme overrides the default "https" used to reach a reference's
+	// domain, for talking to registries under test over plain HTTP.
+	Scheme string
+}
+
+// NewClient returns a Client that talks to registries over HTTPS using
+// http.DefaultClient.
+func NewClient() *Client {
+	return &Client{}
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+func (c *Client) scheme() string {
+	if c.Scheme != "" {
+		return c.Scheme
+	}
+	return "https"
+}
+
+// ManifestOptions controls how Client.Manifest resolves a manifest list or
+// index down to a single manifest.
+type ManifestOptions struct {
+	// Platform, when set, selects the entry of a manifest list/index whose
+	// platform matches. It is ignored when the fetched document is already
+	// a single manifest.
+	Platform *specs.Platform
+
+	// ExpectedDigest, when set, is verified against the content actually
+	// fetched for the reference. When Platform selects an entry from a
+	// manifest list, that is the list itself; the returned platform-specific
+	// manifest is verified against the digest the list declares for it.
+	ExpectedDigest digest.Digest
+}
+
+// Manifest fetches the manifest named by ref from its registry, validates
+// it against the schema package, and returns its media type, raw bytes,
+// and descriptor. If the fetched document is a manifest list or index and
+// opts.Platform is set, Manifest follows the matching entry and returns the
+// platform-specific manifest instead.
+func (c *Client) Manifest(ctx context.Context, ref *reference.Reference, opts ManifestOptions) (string, []byte, specs.Descriptor, error) {
+	alg := digest.SHA256
+	if opts.ExpectedDigest != "" {
+		alg = opts.ExpectedDigest.Algorithm()
+	}
+
+	mediaType, raw, desc, err := c.fetchManifest(ctx, ref, alg)
+	if err != nil {
+		return "", nil, specs.Descriptor{}, err
+	}
+
+	if opts.ExpectedDigest != "" && desc.Digest != opts.ExpectedDigest {
+		return "", nil, specs.Descriptor{}, fmt.Errorf("registry: manifest digest %s does not match expected digest %s", desc.Digest, opts.ExpectedDigest)
+	}
+
+	if err := validate(mediaType, raw); err != nil {
+		return "", nil, specs.Descriptor{}, err
+	}
+
+	if !isManifestList(mediaType) || opts.Platform == nil {
+		return mediaType, raw, desc, nil
+	}
+
+	child, err := selectPlatform(raw, *opts.Platform)
+	if err != nil {
+		return "", nil, specs.Descriptor{}, err
+	}
+
+	childRef := *ref
+	childRef.Tag = ""
+	childRef.Digest = child.Digest
+	// Pin to the digest the manifest list itself declared for this
+	// platform -- not the parent's ExpectedDigest, which names the list,
+	// not the child, and would never match it.
+	return c.Manifest(ctx, &childRef, ManifestOptions{ExpectedDigest: child.Digest})
+}
+
+func (c *Client) fetchManifest(ctx context.Context, ref *reference.Reference, alg digest.Algorithm) (string, []byte, specs.Descriptor, error) {
+	tag := ref.Tag
+	if tag == "" {
+		tag = string(ref.Digest)
+	}
+	if tag == "" {
+		return "", nil, specs.Descriptor{}, fmt.Errorf("registry: reference %s has no tag or digest to fetch", ref)
+	}
+
+	url := fmt.Sprintf("%s://%s/v2/%s/manifests/%s", c.scheme(), ref.Domain, ref.Path, tag)
+
+	raw, mediaType, err := c.get(ctx, url, "")
+	if err != nil {
+		var challenge *authChallenge
+		if asAuthChallenge(err, &challenge) {
+			token, tokenErr := c.authenticate(ctx, challenge, ref)
+			if tokenErr != nil {
+				return "", nil, specs.Descriptor{}, fmt.Errorf("registry: authenticating to %s: %w", ref.Domain, tokenErr)
+			}
+			raw, mediaType, err = c.get(ctx, url, token)
+		}
+		if err != nil {
+			return "", nil, specs.Descriptor{}, err
+		}
+	}
+
+	dgst := alg.FromBytes(raw)
+	desc := specs.Descriptor{
+		MediaType: mediaType,
+		Digest:    dgst,
+		Size:      int64(len(raw)),
+	}
+	return mediaType, raw, desc, nil
+}
+
+func (c *Client) get(ctx context.Context, url, token string) ([]byte, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("registry: building request: %w", err)
+	}
+	for _, mt := range acceptMediaTypes {
+		req.Header.Add("Accept", mt)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("registry: requesting %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge, err := parseAuthChallenge(resp.Header.Get("WWW-Authenticate"))
+		if err != nil {
+			return nil, "", fmt.Errorf("registry: %s returned 401 with an unparsable challenge: %w", url, err)
+		}
+		return nil, "", &authChallengeError{challenge: challenge}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("registry: %s returned unexpected status %s", url, resp.Status)
+	}
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("registry: reading response body from %s: %w", url, err)
+	}
+	return raw, resp.Header.Get("Content-Type"), nil
+}
+
+func isManifestList(mediaType string) bool {
+	return mediaType == convert.OCIMediaTypeIndex || mediaType == convert.DockerMediaTypeManifestList
+}
+
+func validate(mediaType string, raw []byte) error {
+	var mt schema.MediaType
+	switch mediaType {
+	case convert.DockerMediaTypeManifestList, convert.OCIMediaTypeIndex:
+		mt = schema.MediaTypeManifestList
+	case convert.DockerMediaTypeManifest, convert.OCIMediaTypeManifest, convert.DockerMediaTypeSchema1Manifest:
+		mt = schema.MediaTypeManifest
+	default:
+		return fmt.Errorf("registry: unrecognized manifest media type %q", mediaType)
+	}
+
+	if err := mt.Validate(bytes.NewReader(raw)); err != nil {
+		return fmt.Errorf("registry: fetched manifest failed schema validation: %w", err)
+	}
+	return nil
+}
+
+// manifestListEntry is the subset of a manifest list/index entry needed to
+// select a platform.
+type manifestListEntry struct {
+	Digest   digest.Digest  `json:"digest"`
+	Platform specs.Platform `json:"platform"`
+}
+
+func selectPlatform(raw []byte, want specs.Platform) (manifestListEntry, error) {
+	var list struct {
+		Manifests []manifestListEntry `json:"manifests"`
+	}
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return manifestListEntry{}, fmt.Errorf("registry: decoding manifest list: %w", err)
+	}
+
+	for _, m := range list.Manifests {
+		if m.Platform.Architecture == want.Architecture &&
+			m.Platform.OS == want.OS &&
+			(want.Variant == "" || m.Platform.Variant == want.Variant) {
+			return m, nil
+		}
+	}
+	return manifestListEntry{}, fmt.Errorf("registry: no manifest for platform %s/%s (variant %q) in manifest list", want.OS, want.Architecture, want.Variant)
+}
@@ -0,0 +1,70 @@
+// Copyright 2016 The Linux Foundation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"testing"
+
+	specs "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestParseAuthChallenge(t *testing.T) {
+	header := `Bearer realm="https://auth.example.com/token",service="registry.example.com",scope="repository:library/alpine:pull"`
+
+	c, err := parseAuthChallenge(header)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c.realm != "https://auth.example.com/token" {
+		t.Errorf("realm = %q", c.realm)
+	}
+	if c.service != "registry.example.com" {
+		t.Errorf("service = %q", c.service)
+	}
+	if c.scope != "repository:library/alpine:pull" {
+		t.Errorf("scope = %q", c.scope)
+	}
+}
+
+func TestParseAuthChallengeRejectsOtherSchemes(t *testing.T) {
+	if _, err := parseAuthChallenge(`Basic realm="registry"`); err == nil {
+		t.Fatal("expected an error for a non-Bearer scheme, got nil")
+	}
+}
+
+func TestSelectPlatform(t *testing.T) {
+	raw := []byte(`{
+		"manifests": [
+			{"digest": "sha256:aaaa", "platform": {"architecture": "amd64", "os": "linux"}},
+			{"digest": "sha256:bbbb", "platform": {"architecture": "arm64", "os": "linux", "variant": "v8"}}
+		]
+	}`)
+
+	entry, err := selectPlatform(raw, specs.Platform{Architecture: "arm64", OS: "linux", Variant: "v8"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if entry.Digest != "sha256:bbbb" {
+		t.Errorf("expected sha256:bbbb, got %s", entry.Digest)
+	}
+}
+
+func TestSelectPlatformNoMatch(t *testing.T) {
+	raw := []byte(`{"manifests": [{"digest": "sha256:aaaa", "platform": {"architecture": "amd64", "os": "linux"}}]}`)
+
+	if _, err := selectPlatform(raw, specs.Platform{Architecture: "arm64", OS: "linux"}); err == nil {
+		t.Fatal("expected an error when no platform matches, got nil")
+	}
+}
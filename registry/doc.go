@@ -0,0 +1,20 @@
+// Copyright 2016 The Linux Foundation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package registry speaks enough of the OCI Distribution v2 protocol to
+// fetch a manifest by reference and hand it to schema for validation: it
+// issues GET /v2/{name}/manifests/{ref}, follows a bearer-token
+// www-authenticate challenge, and picks a single manifest out of a manifest
+// list/index when the caller supplies a platform to match.
+package registry
@@ -0,0 +1,142 @@
+// Copyright 2016 The Linux Foundation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/opencontainers/image-spec/reference"
+	specs "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+const childManifest = `{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json","config":{"mediaType":"application/vnd.oci.image.config.v1+json","size":2,"digest":"sha256:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"},"layers":[]}`
+
+// TestClientManifestFetchAuthRetryAndPlatformSelect exercises the whole
+// fetch -> 401 challenge -> token exchange -> retry -> validate ->
+// platform-select flow against a real HTTP server, rather than testing its
+// pieces (parseAuthChallenge, selectPlatform) in isolation.
+func TestClientManifestFetchAuthRetryAndPlatformSelect(t *testing.T) {
+	childSum := sha256.Sum256([]byte(childManifest))
+	childDigest := "sha256:" + hex.EncodeToString(childSum[:])
+
+	list := fmt.Sprintf(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.index.v1+json","manifests":[{"mediaType":"application/vnd.oci.image.manifest.v1+json","size":%d,"digest":%q,"platform":{"architecture":"amd64","os":"linux"}}]}`, len(childManifest), childDigest)
+
+	var server *httptest.Server
+	requestsWithoutAuth := 0
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"token": "test-token"})
+	})
+	mux.HandleFunc("/v2/library/alpine/manifests/latest", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			requestsWithoutAuth++
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="registry.example.com"`, server.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/vnd.oci.image.index.v1+json")
+		w.Write([]byte(list))
+	})
+	mux.HandleFunc("/v2/library/alpine/manifests/"+childDigest, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			requestsWithoutAuth++
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="registry.example.com"`, server.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/vnd.oci.image.manifest.v1+json")
+		w.Write([]byte(childManifest))
+	})
+
+	server = httptest.NewServer(mux)
+	defer server.Close()
+
+	ref, err := reference.Parse(strings.TrimPrefix(server.URL, "http://") + "/library/alpine:latest")
+	if err != nil {
+		t.Fatalf("unexpected error parsing reference: %v", err)
+	}
+
+	c := &Client{HTTPClient: server.Client(), Scheme: "http"}
+	mediaType, raw, desc, err := c.Manifest(context.Background(), ref, ManifestOptions{
+		Platform: &specs.Platform{Architecture: "amd64", OS: "linux"},
+	})
+	if err != nil {
+		t.Fatalf("Manifest: unexpected error: %v", err)
+	}
+
+	if mediaType != "application/vnd.oci.image.manifest.v1+json" {
+		t.Errorf("mediaType = %q, want the child manifest's media type", mediaType)
+	}
+	if string(raw) != childManifest {
+		t.Errorf("raw = %q, want the child manifest body", raw)
+	}
+	if desc.Digest.String() != childDigest {
+		t.Errorf("descriptor digest = %s, want %s", desc.Digest, childDigest)
+	}
+	if requestsWithoutAuth != 2 {
+		t.Errorf("expected exactly 2 unauthenticated requests (one per fetch) to trigger the challenge, got %d", requestsWithoutAuth)
+	}
+}
+
+// TestClientManifestRejectsMismatchedChildContent confirms that the content
+// served for a manifest list's platform-selected entry is checked against
+// the digest the list itself declared for that entry -- a registry (or a
+// man-in-the-middle) serving different bytes at that digest URL must be
+// rejected, not accepted silently.
+func TestClientManifestRejectsMismatchedChildContent(t *testing.T) {
+	childSum := sha256.Sum256([]byte(childManifest))
+	childDigest := "sha256:" + hex.EncodeToString(childSum[:])
+
+	tamperedManifest := `{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json","config":{"mediaType":"application/vnd.oci.image.config.v1+json","size":2,"digest":"sha256:bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"},"layers":[]}`
+
+	list := fmt.Sprintf(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.index.v1+json","manifests":[{"mediaType":"application/vnd.oci.image.manifest.v1+json","size":%d,"digest":%q,"platform":{"architecture":"amd64","os":"linux"}}]}`, len(childManifest), childDigest)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v2/library/alpine/manifests/latest", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/vnd.oci.image.index.v1+json")
+		w.Write([]byte(list))
+	})
+	// Serves different content than the list's declared digest names.
+	mux.HandleFunc("/v2/library/alpine/manifests/"+childDigest, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/vnd.oci.image.manifest.v1+json")
+		w.Write([]byte(tamperedManifest))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	ref, err := reference.Parse(strings.TrimPrefix(server.URL, "http://") + "/library/alpine:latest")
+	if err != nil {
+		t.Fatalf("unexpected error parsing reference: %v", err)
+	}
+
+	c := &Client{HTTPClient: server.Client(), Scheme: "http"}
+	_, _, _, err = c.Manifest(context.Background(), ref, ManifestOptions{
+		Platform: &specs.Platform{Architecture: "amd64", OS: "linux"},
+	})
+	if err == nil {
+		t.Fatal("expected an error when the served child manifest doesn't match the list's declared digest, got nil")
+	}
+}
@@ -0,0 +1,207 @@
+// Copyright 2016 The Linux Foundation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// descriptorFieldOrder is the stable field order Canonicalize emits for any
+// JSON object that looks like an OCI descriptor -- one carrying both a
+// mediaType and a digest -- matching the order of the fields in the OCI
+// spec's Descriptor struct.
+var descriptorFieldOrder = []string{
+	"mediaType", "digest", "size", "urls", "annotations", "platform",
+}
+
+// Canonicalize re-emits raw, a manifest/index/config document of the given
+// mediaType, as compact JSON with every embedded descriptor's fields in
+// descriptorFieldOrder, every other object's keys sorted, and every
+// "digest" value lower-cased. It rejects raw if any object in it repeats a
+// key, since a duplicate key means the document's meaning depends on which
+// JSON decoder reads it.
+func Canonicalize(mediaType string, raw []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+
+	val, err := decodeValue(dec)
+	if err != nil {
+		return nil, fmt.Errorf("schema: canonicalizing %s: %w", mediaType, err)
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("schema: canonicalizing %s: trailing data after document", mediaType)
+	}
+
+	out, err := json.Marshal(val)
+	if err != nil {
+		return nil, fmt.Errorf("schema: canonicalizing %s: %w", mediaType, err)
+	}
+	return out, nil
+}
+
+// object is a JSON object decoded with its key order preserved, so
+// Canonicalize can tell whether it looks like a descriptor and, if not,
+// fall back to a stable (sorted) order rather than an arbitrary one.
+type object struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func newObject() *object {
+	return &object{values: map[string]interface{}{}}
+}
+
+func (o *object) set(key string, value interface{}) error {
+	if _, exists := o.values[key]; exists {
+		return fmt.Errorf("duplicate key %q", key)
+	}
+	o.keys = append(o.keys, key)
+	o.values[key] = value
+	return nil
+}
+
+func (o *object) isDescriptor() bool {
+	_, hasMediaType := o.values["mediaType"]
+	_, hasDigest := o.values["digest"]
+	return hasMediaType && hasDigest
+}
+
+// orderedKeys returns o's keys in the order Canonicalize emits them.
+func (o *object) orderedKeys() []string {
+	if !o.isDescriptor() {
+		keys := append([]string(nil), o.keys...)
+		sort.Strings(keys)
+		return keys
+	}
+
+	seen := make(map[string]bool, len(o.keys))
+	ordered := make([]string, 0, len(o.keys))
+	for _, k := range descriptorFieldOrder {
+		if _, ok := o.values[k]; ok {
+			ordered = append(ordered, k)
+			seen[k] = true
+		}
+	}
+	var leftover []string
+	for _, k := range o.keys {
+		if !seen[k] {
+			leftover = append(leftover, k)
+		}
+	}
+	sort.Strings(leftover)
+	return append(ordered, leftover...)
+}
+
+// MarshalJSON implements json.Marshaler so that a nested object marshals
+// itself in orderedKeys order wherever it's embedded -- in particular, via
+// json.Marshal's normal recursion into map/slice-valued fields of an
+// enclosing object or array.
+func (o *object) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, k := range o.orderedKeys() {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		keyJSON, err := json.Marshal(k)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(keyJSON)
+		buf.WriteByte(':')
+		valJSON, err := json.Marshal(o.values[k])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(valJSON)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// decodeValue reads the next complete JSON value from dec.
+func decodeValue(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		// A string, json.Number, bool or nil -- already a usable value.
+		return tok, nil
+	}
+
+	switch delim {
+	case '{':
+		return decodeObject(dec)
+	case '[':
+		return decodeArray(dec)
+	default:
+		return nil, fmt.Errorf("unexpected delimiter %q", delim)
+	}
+}
+
+func decodeObject(dec *json.Decoder) (*object, error) {
+	obj := newObject()
+	for dec.More() {
+		keyTok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, ok := keyTok.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected a string object key, got %v", keyTok)
+		}
+
+		val, err := decodeValue(dec)
+		if err != nil {
+			return nil, err
+		}
+
+		if key == "digest" {
+			if s, ok := val.(string); ok {
+				val = strings.ToLower(s)
+			}
+		}
+
+		if err := obj.set(key, val); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := dec.Token(); err != nil { // consume closing '}'
+		return nil, err
+	}
+	return obj, nil
+}
+
+func decodeArray(dec *json.Decoder) ([]interface{}, error) {
+	values := []interface{}{}
+	for dec.More() {
+		val, err := decodeValue(dec)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, val)
+	}
+	if _, err := dec.Token(); err != nil { // consume closing ']'
+		return nil, err
+	}
+	return values, nil
+}
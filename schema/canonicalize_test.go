@@ -0,0 +1,80 @@
+// Copyright 2016 The Linux Foundation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schema_test
+
+import (
+	"testing"
+
+	"github.com/opencontainers/image-spec/schema"
+)
+
+func TestCanonicalizeReordersDescriptorFields(t *testing.T) {
+	const in = `{
+   "digest": "sha256:ABCDEF",
+   "annotations": {"com.example.key": "value"},
+   "size": 42,
+   "mediaType": "application/vnd.oci.image.layer.v1.tar+gzip"
+}`
+	const want = `{"mediaType":"application/vnd.oci.image.layer.v1.tar+gzip","digest":"sha256:abcdef","size":42,"annotations":{"com.example.key":"value"}}`
+
+	got, err := schema.Canonicalize("application/vnd.oci.image.layer.v1.tar+gzip", []byte(in))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(got) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestCanonicalizeIsIdempotent(t *testing.T) {
+	const in = `{"mediaType":"application/vnd.oci.image.manifest.v1+json","schemaVersion":2,"config":{"digest":"sha256:aa","mediaType":"application/vnd.oci.image.config.v1+json","size":1}}`
+
+	first, err := schema.Canonicalize("application/vnd.oci.image.manifest.v1+json", []byte(in))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	second, err := schema.Canonicalize("application/vnd.oci.image.manifest.v1+json", first)
+	if err != nil {
+		t.Fatalf("unexpected error on re-canonicalizing: %v", err)
+	}
+	if string(first) != string(second) {
+		t.Errorf("canonicalization is not idempotent:\nfirst:  %s\nsecond: %s", first, second)
+	}
+}
+
+func TestCanonicalizeSortsUnknownDescriptorFields(t *testing.T) {
+	const a = `{"mediaType":"application/vnd.oci.image.layer.v1.tar+gzip","digest":"sha256:abcdef","data":"xx","artifactType":"application/vnd.example+type"}`
+	const b = `{"artifactType":"application/vnd.example+type","mediaType":"application/vnd.oci.image.layer.v1.tar+gzip","data":"xx","digest":"sha256:abcdef"}`
+
+	gotA, err := schema.Canonicalize("application/vnd.oci.image.layer.v1.tar+gzip", []byte(a))
+	if err != nil {
+		t.Fatalf("unexpected error canonicalizing a: %v", err)
+	}
+	gotB, err := schema.Canonicalize("application/vnd.oci.image.layer.v1.tar+gzip", []byte(b))
+	if err != nil {
+		t.Fatalf("unexpected error canonicalizing b: %v", err)
+	}
+	if string(gotA) != string(gotB) {
+		t.Errorf("descriptors with the same fields in different order canonicalized differently:\na: %s\nb: %s", gotA, gotB)
+	}
+}
+
+func TestCanonicalizeRejectsDuplicateKeys(t *testing.T) {
+	const in = `{"mediaType":"a","mediaType":"b"}`
+
+	if _, err := schema.Canonicalize("application/vnd.oci.image.manifest.v1+json", []byte(in)); err == nil {
+		t.Fatal("expected an error for a document with a duplicate key, got nil")
+	}
+}
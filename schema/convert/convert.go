@@ -0,0 +1,286 @@
+// Copyright 2016 The Linux Foundation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package convert
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Docker Distribution v2.2 media types.
+const (
+	DockerMediaTypeManifest     = "application/vnd.docker.distribution.manifest.v2+json"
+	DockerMediaTypeManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+	DockerMediaTypeConfig       = "application/vnd.docker.container.image.v1+json"
+	DockerMediaTypeLayer        = "application/vnd.docker.image.rootfs.diff.tar.gzip"
+	DockerMediaTypeForeignLayer = "application/vnd.docker.image.rootfs.foreign.diff.tar.gzip"
+)
+
+// OCI image-spec media types.
+const (
+	OCIMediaTypeManifest = "application/vnd.oci.image.manifest.v1+json"
+	OCIMediaTypeIndex    = "application/vnd.oci.image.index.v1+json"
+	OCIMediaTypeConfig   = "application/vnd.oci.image.config.v1+json"
+	OCIMediaTypeLayer    = "application/vnd.oci.image.layer.v1.tar+gzip"
+)
+
+// toOCIMediaType and toDockerMediaType translate individual media
+// type strings between the two ecosystems. They are consulted only for the
+// specific fields known to carry a media type (a descriptor's mediaType, or
+// the document's own top-level mediaType) -- never applied as a substring
+// replacement over raw bytes.
+var toOCIMediaType = map[string]string{
+	DockerMediaTypeManifest:     OCIMediaTypeManifest,
+	DockerMediaTypeManifestList: OCIMediaTypeIndex,
+	DockerMediaTypeConfig:       OCIMediaTypeConfig,
+	DockerMediaTypeLayer:        OCIMediaTypeLayer,
+}
+
+var toDockerMediaType = map[string]string{
+	OCIMediaTypeManifest: DockerMediaTypeManifest,
+	OCIMediaTypeIndex:    DockerMediaTypeManifestList,
+	OCIMediaTypeConfig:   DockerMediaTypeConfig,
+	OCIMediaTypeLayer:    DockerMediaTypeLayer,
+}
+
+// Format identifies the concrete shape of a manifest document as reported
+// by DetectFormat.
+type Format string
+
+// Recognized formats.
+const (
+	FormatDockerManifest     Format = "docker-manifest-v2.2"
+	FormatDockerManifestList Format = "docker-manifest-list-v2.2"
+	FormatDockerSchema1      Format = "docker-manifest-v1"
+	FormatOCIManifest        Format = "oci-manifest"
+	FormatOCIIndex           Format = "oci-index"
+	FormatImageConfig        Format = "image-config"
+	FormatUnknown            Format = "unknown"
+)
+
+// probe is the minimal shape every supported document decodes into far
+// enough to be classified.
+type probe struct {
+	SchemaVersion int    `json:"schemaVersion"`
+	MediaType     string `json:"mediaType"`
+	// FSLayers is only present on Docker schema1 manifests, which carry no
+	// mediaType field at all.
+	FSLayers json.RawMessage `json:"fsLayers"`
+	// RootFS is only present on a bare image config, which -- like
+	// schema1 -- carries no mediaType field of its own.
+	RootFS json.RawMessage `json:"rootfs"`
+}
+
+// DetectFormat inspects, without fully validating, enough of r to classify
+// the manifest document it contains. r is consumed; callers that also need
+// to convert the document must re-read it from the start.
+func DetectFormat(r io.Reader) (Format, error) {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return FormatUnknown, fmt.Errorf("convert: reading document: %w", err)
+	}
+
+	var p probe
+	if err := json.Unmarshal(raw, &p); err != nil {
+		return FormatUnknown, fmt.Errorf("convert: decoding document: %w", err)
+	}
+
+	switch {
+	case p.MediaType == DockerMediaTypeManifest:
+		return FormatDockerManifest, nil
+	case p.MediaType == DockerMediaTypeManifestList:
+		return FormatDockerManifestList, nil
+	case p.MediaType == OCIMediaTypeManifest:
+		return FormatOCIManifest, nil
+	case p.MediaType == OCIMediaTypeIndex:
+		return FormatOCIIndex, nil
+	case p.SchemaVersion == 1 && p.FSLayers != nil:
+		return FormatDockerSchema1, nil
+	case p.MediaType == "" && p.FSLayers == nil && p.RootFS != nil:
+		return FormatImageConfig, nil
+	default:
+		return FormatUnknown, fmt.Errorf("convert: unrecognized manifest document")
+	}
+}
+
+// rawDoc is a JSON object decoded field-by-field so that any key this
+// package does not know about is preserved verbatim when the document is
+// re-emitted.
+type rawDoc map[string]json.RawMessage
+
+func decodeRawDoc(raw []byte) (rawDoc, error) {
+	var d rawDoc
+	if err := json.Unmarshal(raw, &d); err != nil {
+		return nil, fmt.Errorf("convert: decoding document: %w", err)
+	}
+	return d, nil
+}
+
+func (d rawDoc) setString(key, value string) {
+	encoded, _ := json.Marshal(value)
+	d[key] = encoded
+}
+
+// convertDescriptorMediaType decodes the raw descriptor at d[key], rewrites
+// its mediaType field using translate, and writes it back. Descriptors that
+// are arrays (config is a single object, layers/manifests are arrays) are
+// handled by convertDescriptorListMediaType instead.
+func convertDescriptorMediaType(d rawDoc, key string, translate map[string]string) error {
+	raw, ok := d[key]
+	if !ok {
+		return nil
+	}
+	desc, err := decodeRawDoc(raw)
+	if err != nil {
+		return fmt.Errorf("convert: %s: %w", key, err)
+	}
+	if err := rewriteMediaType(desc, translate); err != nil {
+		return fmt.Errorf("convert: %s: %w", key, err)
+	}
+	encoded, err := json.Marshal(desc)
+	if err != nil {
+		return fmt.Errorf("convert: %s: %w", key, err)
+	}
+	d[key] = encoded
+	return nil
+}
+
+func convertDescriptorListMediaType(d rawDoc, key string, translate map[string]string) error {
+	raw, ok := d[key]
+	if !ok {
+		return nil
+	}
+	var list []rawDoc
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return fmt.Errorf("convert: %s: %w", key, err)
+	}
+	for _, desc := range list {
+		if err := rewriteMediaType(desc, translate); err != nil {
+			return fmt.Errorf("convert: %s: %w", key, err)
+		}
+	}
+	encoded, err := json.Marshal(list)
+	if err != nil {
+		return fmt.Errorf("convert: %s: %w", key, err)
+	}
+	d[key] = encoded
+	return nil
+}
+
+func rewriteMediaType(desc rawDoc, translate map[string]string) error {
+	raw, ok := desc["mediaType"]
+	if !ok {
+		return nil
+	}
+	var mt string
+	if err := json.Unmarshal(raw, &mt); err != nil {
+		return fmt.Errorf("decoding mediaType: %w", err)
+	}
+	if replacement, ok := translate[mt]; ok {
+		mt = replacement
+	}
+	desc.setString("mediaType", mt)
+	return nil
+}
+
+// ToOCI converts a Docker Distribution v2.2 manifest, manifest list or image
+// config read from r into its OCI equivalent. A converted v2.2 manifest or
+// manifest list keeps its unknown fields and is re-emitted as compact JSON,
+// but with no particular field order -- use schema.Canonicalize for that.
+// A bare image config or a document that is already OCI is returned
+// unchanged, and a schema1 manifest is rebuilt by schema1ToOCI.
+func ToOCI(r io.Reader) ([]byte, error) {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("convert: reading document: %w", err)
+	}
+
+	format, err := DetectFormat(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case FormatDockerSchema1:
+		return schema1ToOCI(raw)
+	case FormatOCIManifest, FormatOCIIndex, FormatImageConfig:
+		return raw, nil
+	case FormatDockerManifest:
+		return convertManifest(raw, toOCIMediaType)
+	case FormatDockerManifestList:
+		return convertManifestList(raw, toOCIMediaType)
+	default:
+		return nil, fmt.Errorf("convert: cannot convert %s to OCI", format)
+	}
+}
+
+// ToDocker converts an OCI image manifest, index or image config read from
+// r into its Docker Distribution v2.2 equivalent, preserving unknown
+// fields.
+func ToDocker(r io.Reader) ([]byte, error) {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("convert: reading document: %w", err)
+	}
+
+	format, err := DetectFormat(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case FormatDockerManifest, FormatDockerManifestList, FormatDockerSchema1, FormatImageConfig:
+		return raw, nil
+	case FormatOCIManifest:
+		return convertManifest(raw, toDockerMediaType)
+	case FormatOCIIndex:
+		return convertManifestList(raw, toDockerMediaType)
+	default:
+		return nil, fmt.Errorf("convert: cannot convert %s to Docker", format)
+	}
+}
+
+func convertManifest(raw []byte, translate map[string]string) ([]byte, error) {
+	d, err := decodeRawDoc(raw)
+	if err != nil {
+		return nil, err
+	}
+	if err := rewriteMediaType(d, translate); err != nil {
+		return nil, fmt.Errorf("convert: top-level mediaType: %w", err)
+	}
+	if err := convertDescriptorMediaType(d, "config", translate); err != nil {
+		return nil, err
+	}
+	if err := convertDescriptorListMediaType(d, "layers", translate); err != nil {
+		return nil, err
+	}
+	return json.Marshal(d)
+}
+
+func convertManifestList(raw []byte, translate map[string]string) ([]byte, error) {
+	d, err := decodeRawDoc(raw)
+	if err != nil {
+		return nil, err
+	}
+	if err := rewriteMediaType(d, translate); err != nil {
+		return nil, fmt.Errorf("convert: top-level mediaType: %w", err)
+	}
+	if err := convertDescriptorListMediaType(d, "manifests", translate); err != nil {
+		return nil, err
+	}
+	return json.Marshal(d)
+}
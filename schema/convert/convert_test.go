@@ -0,0 +1,143 @@
+// Copyright 2016 The Linux Foundation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package convert_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/opencontainers/image-spec/schema/convert"
+)
+
+const dockerManifest = `{
+   "schemaVersion": 2,
+   "mediaType": "application/vnd.docker.distribution.manifest.v2+json",
+   "config": {
+      "mediaType": "application/vnd.docker.container.image.v1+json",
+      "size": 3210,
+      "digest": "sha256:5359a4f250650c20227055957e353e8f8a74152f35fe36f00b6b1f9fc19c8861"
+   },
+   "layers": [
+      {
+         "mediaType": "application/vnd.docker.image.rootfs.diff.tar.gzip",
+         "size": 2310272,
+         "digest": "sha256:fae91920dcd4542f97c9350b3157139a5d901362c2abec284de5ebd1b45b4957",
+         "annotations": {
+            "com.example.history": "contains application/vnd.docker.container.image.v1+json as plain text"
+         }
+      }
+   ]
+}`
+
+const imageConfig = `{
+   "architecture": "amd64",
+   "os": "linux",
+   "config": {
+      "Env": ["PATH=/usr/bin"]
+   },
+   "rootfs": {
+      "type": "layers",
+      "diff_ids": ["sha256:fae91920dcd4542f97c9350b3157139a5d901362c2abec284de5ebd1b45b4957"]
+   }
+}`
+
+func TestDetectFormatImageConfig(t *testing.T) {
+	format, err := convert.DetectFormat(strings.NewReader(imageConfig))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if format != convert.FormatImageConfig {
+		t.Errorf("expected %s, got %s", convert.FormatImageConfig, format)
+	}
+}
+
+func TestToOCIImageConfigPassesThrough(t *testing.T) {
+	// The Docker and OCI config wire formats are identical, so ToOCI/ToDocker
+	// should pass a bare config through unchanged rather than erroring out.
+	oci, err := convert.ToOCI(strings.NewReader(imageConfig))
+	if err != nil {
+		t.Fatalf("ToOCI: unexpected error: %v", err)
+	}
+	if string(oci) != imageConfig {
+		t.Errorf("ToOCI modified a bare image config:\ngot:  %s\nwant: %s", oci, imageConfig)
+	}
+
+	docker, err := convert.ToDocker(strings.NewReader(imageConfig))
+	if err != nil {
+		t.Fatalf("ToDocker: unexpected error: %v", err)
+	}
+	if string(docker) != imageConfig {
+		t.Errorf("ToDocker modified a bare image config:\ngot:  %s\nwant: %s", docker, imageConfig)
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	format, err := convert.DetectFormat(strings.NewReader(dockerManifest))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if format != convert.FormatDockerManifest {
+		t.Errorf("expected %s, got %s", convert.FormatDockerManifest, format)
+	}
+}
+
+func TestToOCIRoundTrip(t *testing.T) {
+	oci, err := convert.ToOCI(strings.NewReader(dockerManifest))
+	if err != nil {
+		t.Fatalf("ToOCI: unexpected error: %v", err)
+	}
+
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(oci, &decoded); err != nil {
+		t.Fatalf("decoding converted manifest: %v", err)
+	}
+
+	if mt := decoded["mediaType"]; mt != convert.OCIMediaTypeManifest {
+		t.Errorf("expected top-level mediaType %s, got %v", convert.OCIMediaTypeManifest, mt)
+	}
+
+	config := decoded["config"].(map[string]interface{})
+	if mt := config["mediaType"]; mt != convert.OCIMediaTypeConfig {
+		t.Errorf("expected config mediaType %s, got %v", convert.OCIMediaTypeConfig, mt)
+	}
+
+	layers := decoded["layers"].([]interface{})
+	layer := layers[0].(map[string]interface{})
+	if mt := layer["mediaType"]; mt != convert.OCIMediaTypeLayer {
+		t.Errorf("expected layer mediaType %s, got %v", convert.OCIMediaTypeLayer, mt)
+	}
+
+	// The media-type string embedded in the annotation's value must survive
+	// untouched -- this is the case the old strings.Replace helper got wrong.
+	annotations := layer["annotations"].(map[string]interface{})
+	want := "contains application/vnd.docker.container.image.v1+json as plain text"
+	if got := annotations["com.example.history"]; got != want {
+		t.Errorf("annotation was corrupted by conversion: got %q, want %q", got, want)
+	}
+
+	docker, err := convert.ToDocker(strings.NewReader(string(oci)))
+	if err != nil {
+		t.Fatalf("ToDocker: unexpected error: %v", err)
+	}
+
+	format, err := convert.DetectFormat(strings.NewReader(string(docker)))
+	if err != nil {
+		t.Fatalf("DetectFormat on round-tripped manifest: %v", err)
+	}
+	if format != convert.FormatDockerManifest {
+		t.Errorf("round trip: expected %s, got %s", convert.FormatDockerManifest, format)
+	}
+}
@@ -0,0 +1,30 @@
+// Copyright 2016 The Linux Foundation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package convert implements structural, bidirectional conversion between
+// Docker Distribution v2.2 manifests, manifest lists and image configs and
+// their OCI equivalents.
+//
+// Conversion is performed by decoding the input one JSON object at a time
+// (as map[string]json.RawMessage, not the typed OCI/Docker structs) and
+// translating only the media types carried by the document and its
+// descriptors, rather than by substituting media-type strings inside the
+// raw bytes. Every other field, known or not, passes through untouched.
+// This keeps conversion correct even when a media-type string happens to
+// appear inside unrelated content, such as a history entry or an annotation
+// value, and it preserves fields this package doesn't know about. Bare
+// image configs, which carry no mediaType field of their own, pass through
+// unchanged: the Docker and OCI config wire formats are identical, so there
+// is nothing to translate.
+package convert
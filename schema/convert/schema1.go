@@ -0,0 +1,203 @@
+// Copyright 2016 The Linux Foundation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package convert
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"regexp"
+)
+
+// DockerMediaTypeSchema1Manifest is the media type of a (signed or
+// unsigned) Docker Distribution schema1 manifest. Schema1 documents carry
+// no "mediaType" field of their own, so this constant exists only for
+// callers that want to label the format explicitly; DetectFormat relies on
+// the "fsLayers" field instead.
+const DockerMediaTypeSchema1Manifest = "application/vnd.docker.distribution.manifest.v1+json"
+
+// Schema1FSLayer is a single entry of a schema1 manifest's "fsLayers" list,
+// ordered most-recent-first.
+type Schema1FSLayer struct {
+	BlobSum string `json:"blobSum"`
+}
+
+// Schema1History is a single entry of a schema1 manifest's "history" list,
+// aligned index-for-index with FSLayers.
+type Schema1History struct {
+	V1Compatibility string `json:"v1Compatibility"`
+}
+
+// Schema1Manifest is a Docker Distribution schema1 image manifest, signed
+// or unsigned. Signatures, when present, are preserved verbatim but are not
+// otherwise interpreted.
+type Schema1Manifest struct {
+	SchemaVersion int              `json:"schemaVersion"`
+	Name          string           `json:"name"`
+	Tag           string           `json:"tag"`
+	Architecture  string           `json:"architecture,omitempty"`
+	FSLayers      []Schema1FSLayer `json:"fsLayers"`
+	History       []Schema1History `json:"history"`
+	Signatures    json.RawMessage  `json:"signatures,omitempty"`
+}
+
+// v1Image is the subset of a Docker v1 image JSON blob (the content of a
+// history entry's V1Compatibility string) that fixManifestLayers and the
+// config synthesis in schema1ToOCI need.
+type v1Image struct {
+	ID      string          `json:"id"`
+	Parent  string          `json:"parent,omitempty"`
+	Config  json.RawMessage `json:"config,omitempty"`
+	Created string          `json:"created,omitempty"`
+}
+
+var v1IDPattern = regexp.MustCompile(`^[a-f0-9]{64}$`)
+
+// fixManifestLayers ports the layer-repair logic from Moby's
+// distribution/pull_v2.go fixManifestLayers. Schema1 manifests list one
+// fsLayer/history pair per image layer, most-recent-first, but a manifest
+// may repeat the same (blobSum, v1Compatibility) pair for a layer that sits
+// on top of itself in the history (an empty `docker build` step, for
+// example); those adjacent duplicates are collapsed to a single entry. A
+// duplicate ID that is NOT adjacent indicates the parent chain cycles back
+// on itself and is rejected, as is any entry whose parent does not match
+// the ID of the entry below it.
+func fixManifestLayers(m *Schema1Manifest) error {
+	if len(m.FSLayers) != len(m.History) {
+		return fmt.Errorf("convert: schema1 manifest has %d fsLayers but %d history entries", len(m.FSLayers), len(m.History))
+	}
+
+	imgs := make([]*v1Image, len(m.History))
+	for i, h := range m.History {
+		img := &v1Image{}
+		if err := json.Unmarshal([]byte(h.V1Compatibility), img); err != nil {
+			return fmt.Errorf("convert: decoding history entry %d: %w", i, err)
+		}
+		if !v1IDPattern.MatchString(img.ID) {
+			return fmt.Errorf("convert: invalid v1 image ID %q at history entry %d", img.ID, i)
+		}
+		imgs[i] = img
+	}
+
+	if len(imgs) > 0 && imgs[len(imgs)-1].Parent != "" {
+		return fmt.Errorf("convert: base layer %q must not have a parent", imgs[len(imgs)-1].ID)
+	}
+
+	// A duplicate ID that isn't adjacent means the chain loops back on
+	// itself; walk the whole list once up front so that case is reported as
+	// a cycle rather than as a broken parent link or, worse, an infinite
+	// loop in a caller that walks the chain by following Parent pointers.
+	seen := make(map[string]struct{}, len(imgs))
+	var lastID string
+	for _, img := range imgs {
+		if _, ok := seen[img.ID]; ok && img.ID != lastID {
+			return fmt.Errorf("convert: layer ID %q appears multiple times in non-adjacent history entries (cycle)", img.ID)
+		}
+		seen[img.ID] = struct{}{}
+		lastID = img.ID
+	}
+
+	// Walk bottom-up so that removing a duplicate at index i doesn't shift
+	// the index of an as-yet-unvisited entry above it.
+	for i := len(imgs) - 2; i >= 0; i-- {
+		switch {
+		case imgs[i].ID == imgs[i+1].ID:
+			imgs = append(imgs[:i], imgs[i+1:]...)
+			m.FSLayers = append(m.FSLayers[:i], m.FSLayers[i+1:]...)
+			m.History = append(m.History[:i], m.History[i+1:]...)
+		case imgs[i].Parent != imgs[i+1].ID:
+			return fmt.Errorf("convert: broken parent chain: history entry %d has parent %q, expected %q", i, imgs[i].Parent, imgs[i+1].ID)
+		}
+	}
+
+	return nil
+}
+
+// schema1ToOCI converts a (already schema1-detected) Docker manifest to an
+// OCI image manifest. It synthesizes an OCI image config from the history
+// and records that config's digest and size in the manifest's config
+// descriptor; only the manifest is returned, not the config's bytes.
+//
+// Schema1 carries no layer sizes and no uncompressed ("diff ID") digests,
+// only the gzipped blobSum for each layer -- so the synthesized config's
+// rootfs.diff_ids is always left empty and the layer descriptors carry no
+// size.
+func schema1ToOCI(raw []byte) ([]byte, error) {
+	var m Schema1Manifest
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return nil, fmt.Errorf("convert: decoding schema1 manifest: %w", err)
+	}
+
+	if err := fixManifestLayers(&m); err != nil {
+		return nil, err
+	}
+
+	// The topmost (most recent) history entry carries the image's current
+	// config; schema1 lists layers most-recent-first, so that's index 0.
+	var top v1Image
+	if len(m.History) > 0 {
+		if err := json.Unmarshal([]byte(m.History[0].V1Compatibility), &top); err != nil {
+			return nil, fmt.Errorf("convert: decoding top history entry: %w", err)
+		}
+	}
+
+	config := rawDoc{
+		"architecture": mustMarshal(m.Architecture),
+		"rootfs": mustMarshal(map[string]interface{}{
+			"type":     "layers",
+			"diff_ids": []string{}, // see caveat above
+		}),
+	}
+	if top.Config != nil {
+		config["config"] = top.Config
+	}
+	if top.Created != "" {
+		config["created"] = mustMarshal(top.Created)
+	}
+	configBytes, err := json.Marshal(config)
+	if err != nil {
+		return nil, fmt.Errorf("convert: encoding synthesized config: %w", err)
+	}
+
+	// OCI orders layers base-first; schema1 orders fsLayers top-first.
+	layers := make([]rawDoc, len(m.FSLayers))
+	for i, l := range m.FSLayers {
+		layers[len(layers)-1-i] = rawDoc{
+			"mediaType": mustMarshal(OCIMediaTypeLayer),
+			"digest":    mustMarshal(l.BlobSum),
+		}
+	}
+
+	manifest := rawDoc{
+		"schemaVersion": mustMarshal(2),
+		"mediaType":     mustMarshal(OCIMediaTypeManifest),
+		"config": mustMarshal(rawDoc{
+			"mediaType": mustMarshal(OCIMediaTypeConfig),
+			"digest":    mustMarshal(fmt.Sprintf("sha256:%x", sha256.Sum256(configBytes))),
+			"size":      mustMarshal(len(configBytes)),
+		}),
+		"layers": mustMarshal(layers),
+	}
+
+	return json.Marshal(manifest)
+}
+
+func mustMarshal(v interface{}) json.RawMessage {
+	b, err := json.Marshal(v)
+	if err != nil {
+		panic(fmt.Sprintf("convert: marshaling internal value: %v", err))
+	}
+	return b
+}
@@ -0,0 +1,93 @@
+// Copyright 2016 The Linux Foundation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package convert
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const (
+	idA = "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	idB = "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+	idC = "cccccccccccccccccccccccccccccccccccccccccccccccccccccccccccccc"
+)
+
+func v1Compat(id, parent string) string {
+	if parent == "" {
+		return fmt.Sprintf(`{"id":%q}`, id)
+	}
+	return fmt.Sprintf(`{"id":%q,"parent":%q}`, id, parent)
+}
+
+func manifestFrom(entries ...[2]string) *Schema1Manifest {
+	m := &Schema1Manifest{SchemaVersion: 1}
+	for _, e := range entries {
+		id, parent := e[0], e[1]
+		m.FSLayers = append(m.FSLayers, Schema1FSLayer{BlobSum: "sha256:" + id})
+		m.History = append(m.History, Schema1History{V1Compatibility: v1Compat(id, parent)})
+	}
+	return m
+}
+
+func TestFixManifestLayersCollapsesAdjacentDuplicates(t *testing.T) {
+	// A sits on top of itself (an empty history step) before B, the base.
+	m := manifestFrom([2]string{idA, idB}, [2]string{idA, idB}, [2]string{idB, ""})
+
+	if err := fixManifestLayers(m); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(m.FSLayers) != 2 || len(m.History) != 2 {
+		t.Fatalf("expected duplicate adjacent entry to collapse to 2 layers, got %d fsLayers/%d history", len(m.FSLayers), len(m.History))
+	}
+}
+
+func TestFixManifestLayersRejectsCycle(t *testing.T) {
+	// A and B each claim the other as parent, with A repeated at the base --
+	// a non-adjacent duplicate ID, i.e. a cycle.
+	m := manifestFrom([2]string{idA, idB}, [2]string{idB, idA}, [2]string{idA, ""})
+
+	err := fixManifestLayers(m)
+	if err == nil {
+		t.Fatal("expected an error for a cyclical parent chain, got nil")
+	}
+	if !strings.Contains(err.Error(), "cycle") {
+		t.Errorf("expected a cycle error, got: %v", err)
+	}
+}
+
+func TestFixManifestLayersRejectsBrokenParentChain(t *testing.T) {
+	// A claims C as its parent, but the next entry down is B.
+	m := manifestFrom([2]string{idA, idC}, [2]string{idB, ""})
+
+	err := fixManifestLayers(m)
+	if err == nil {
+		t.Fatal("expected an error for a broken parent chain, got nil")
+	}
+	if !strings.Contains(err.Error(), "broken parent chain") {
+		t.Errorf("expected a broken parent chain error, got: %v", err)
+	}
+}
+
+func TestFixManifestLayersRejectsParentedBaseLayer(t *testing.T) {
+	// The base layer (last entry) must not declare a parent of its own.
+	m := manifestFrom([2]string{idA, idB}, [2]string{idB, idC})
+
+	if err := fixManifestLayers(m); err == nil {
+		t.Fatal("expected an error when the base layer has a parent, got nil")
+	}
+}
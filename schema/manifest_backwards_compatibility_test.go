@@ -15,6 +15,7 @@
 package schema_test
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
@@ -22,26 +23,9 @@ import (
 	"testing"
 
 	"github.com/opencontainers/image-spec/schema"
+	"github.com/opencontainers/image-spec/schema/convert"
 )
 
-var compatMap = map[string]string{
-	"application/vnd.docker.distribution.manifest.list.v2+json": "application/vnd.oci.image.manifest.list.v1+json",
-	"application/vnd.docker.distribution.manifest.v2+json":      "application/vnd.oci.image.manifest.v1+json",
-	"application/vnd.docker.image.rootfs.diff.tar.gzip":         "application/vnd.oci.image.rootfs.tar.gzip",
-	"application/vnd.docker.container.image.v1+json":            "application/vnd.oci.image.serialization.config.v1+json",
-}
-
-// convertFormats converts Docker v2.2 image format JSON documents to OCI
-// format by simply replacing instances of the strings found in the compatMap
-// found in the input string.
-func convertFormats(input string) string {
-	out := input
-	for k, v := range compatMap {
-		out = strings.Replace(out, v, k, -1)
-	}
-	return out
-}
-
 func TestBackwardsCompatibilityManifestList(t *testing.T) {
 	for i, tt := range []struct {
 		manifest string
@@ -115,9 +99,12 @@ func TestBackwardsCompatibilityManifestList(t *testing.T) {
 			t.Errorf("test %d: expected digest %s but got %s", i, tt.digest, got)
 		}
 
-		manifest := convertFormats(tt.manifest)
-		r := strings.NewReader(manifest)
-		err := schema.MediaTypeManifestList.Validate(r)
+		oci, err := convert.ToOCI(strings.NewReader(tt.manifest))
+		if err != nil {
+			t.Errorf("test %d: converting to OCI: %v", i, err)
+			continue
+		}
+		err = schema.MediaTypeManifestList.Validate(bytes.NewReader(oci))
 
 		if got := err != nil; tt.fail != got {
 			t.Errorf("test %d: expected validation failure %t but got %t, err %v", i, tt.fail, got, err)
@@ -178,9 +165,12 @@ func TestBackwardsCompatibilityManifest(t *testing.T) {
 			t.Errorf("test %d: expected digest %s but got %s", i, tt.digest, got)
 		}
 
-		manifest := convertFormats(tt.manifest)
-		r := strings.NewReader(manifest)
-		err := schema.MediaTypeManifest.Validate(r)
+		oci, err := convert.ToOCI(strings.NewReader(tt.manifest))
+		if err != nil {
+			t.Errorf("test %d: converting to OCI: %v", i, err)
+			continue
+		}
+		err = schema.MediaTypeManifest.Validate(bytes.NewReader(oci))
 
 		if got := err != nil; tt.fail != got {
 			t.Errorf("test %d: expected validation failure %t but got %t, err %v", i, tt.fail, got, err)
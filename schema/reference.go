@@ -0,0 +1,52 @@
+// Copyright 2016 The Linux Foundation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schema
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	digest "github.com/opencontainers/go-digest"
+
+	"github.com/opencontainers/image-spec/reference"
+)
+
+// ValidateWithReference validates r the same way Validate does, and
+// additionally, when ref carries a digest, verifies that the manifest's own
+// content hashes to that digest. This lets callers that parsed a
+// name:tag@digest reference confirm the manifest they fetched is the one
+// the reference actually names, not merely one that happens to pass schema
+// validation.
+func (mt MediaType) ValidateWithReference(r io.Reader, ref *reference.Reference) error {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("schema: reading document: %w", err)
+	}
+
+	if err := mt.Validate(bytes.NewReader(raw)); err != nil {
+		return err
+	}
+
+	if ref == nil || ref.Digest == "" {
+		return nil
+	}
+
+	got := ref.Digest.Algorithm().FromBytes(raw)
+	if got != ref.Digest {
+		return &DigestMismatchError{Expected: ref.Digest, Actual: got}
+	}
+	return nil
+}
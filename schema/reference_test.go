@@ -0,0 +1,77 @@
+// Copyright 2016 The Linux Foundation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schema_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/opencontainers/image-spec/reference"
+	"github.com/opencontainers/image-spec/schema"
+)
+
+func TestValidateWithReferenceDigestMismatch(t *testing.T) {
+	manifest := `{
+   "schemaVersion": 2,
+   "mediaType": "application/vnd.docker.distribution.manifest.v2+json",
+   "config": {
+      "mediaType": "application/octet-stream",
+      "size": 3210,
+      "digest": "sha256:5359a4f250650c20227055957e353e8f8a74152f35fe36f00b6b1f9fc19c8861"
+   },
+   "layers": []
+}`
+
+	ref, err := reference.Parse("example.com/library/test@sha256:0000000000000000000000000000000000000000000000000000000000000000")
+	if err != nil {
+		t.Fatalf("unexpected error parsing reference: %v", err)
+	}
+
+	err = schema.MediaTypeManifest.ValidateWithReference(strings.NewReader(manifest), ref)
+	if err == nil {
+		t.Fatal("expected a digest mismatch error, got nil")
+	}
+
+	var mismatch *schema.DigestMismatchError
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("expected a *schema.DigestMismatchError, got %T: %v", err, err)
+	}
+	if mismatch.Expected != ref.Digest {
+		t.Errorf("mismatch.Expected = %s, want %s", mismatch.Expected, ref.Digest)
+	}
+}
+
+func TestValidateWithReferenceNoDigest(t *testing.T) {
+	manifest := `{
+   "schemaVersion": 2,
+   "mediaType": "application/vnd.docker.distribution.manifest.v2+json",
+   "config": {
+      "mediaType": "application/octet-stream",
+      "size": 3210,
+      "digest": "sha256:5359a4f250650c20227055957e353e8f8a74152f35fe36f00b6b1f9fc19c8861"
+   },
+   "layers": []
+}`
+
+	ref, err := reference.Parse("example.com/library/test:latest")
+	if err != nil {
+		t.Fatalf("unexpected error parsing reference: %v", err)
+	}
+
+	if err := schema.MediaTypeManifest.ValidateWithReference(strings.NewReader(manifest), ref); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
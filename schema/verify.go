@@ -0,0 +1,105 @@
+// Copyright 2016 The Linux Foundation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schema
+
+import (
+	"fmt"
+	"io"
+
+	digest "github.com/opencontainers/go-digest"
+
+	// Register the digest algorithms VerifyingValidator is allowed to
+	// verify.
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+)
+
+// VerifyingValidator validates a manifest document against its MediaType's
+// JSON schema while simultaneously hashing it, so the two checks -- "is
+// this well-formed?" and "is this the document the caller asked for?" --
+// happen in a single pass over the input instead of requiring the whole
+// document to be buffered in memory first. This matters for fat manifest
+// lists and artifact manifests that can run to hundreds of megabytes.
+type VerifyingValidator struct {
+	MediaType MediaType
+	Expected  digest.Digest
+}
+
+// NewVerifyingValidator returns a VerifyingValidator for mt that verifies
+// its input hashes to expected. It returns an error if expected's algorithm
+// is not one the OCI spec allows (sha256, sha512).
+func NewVerifyingValidator(mt MediaType, expected digest.Digest) (*VerifyingValidator, error) {
+	switch expected.Algorithm() {
+	case digest.SHA256, digest.SHA512:
+	default:
+		return nil, fmt.Errorf("schema: unsupported digest algorithm %q", expected.Algorithm())
+	}
+	return &VerifyingValidator{MediaType: mt, Expected: expected}, nil
+}
+
+// DigestMismatchError reports that a document was well-formed but did not
+// hash to the digest it was expected to.
+type DigestMismatchError struct {
+	Expected digest.Digest
+	Actual   digest.Digest
+}
+
+func (e *DigestMismatchError) Error() string {
+	return fmt.Sprintf("schema: document digest %s does not match expected digest %s", e.Actual, e.Expected)
+}
+
+// SchemaError wraps a failure from the underlying JSON schema validation,
+// keeping it distinguishable from a DigestMismatchError.
+type SchemaError struct {
+	Err error
+}
+
+func (e *SchemaError) Error() string { return e.Err.Error() }
+func (e *SchemaError) Unwrap() error { return e.Err }
+
+// Validate streams r through schema validation and digest verification at
+// once, in the fixed-size chunks the underlying JSON decoder reads in, and
+// reports a *SchemaError or *DigestMismatchError depending on which check
+// failed.
+func (v *VerifyingValidator) Validate(r io.Reader) error {
+	digester := v.Expected.Algorithm().Digester()
+	tee := io.TeeReader(r, digester.Hash())
+
+	if err := v.MediaType.Validate(tee); err != nil {
+		return &SchemaError{Err: err}
+	}
+
+	// Validate may stop reading before EOF once it has everything it needs
+	// to decide the document is well-formed; drain whatever's left so the
+	// digest covers every byte.
+	if _, err := io.Copy(digester.Hash(), r); err != nil {
+		return fmt.Errorf("schema: reading remainder of document: %w", err)
+	}
+
+	if actual := digester.Digest(); actual != v.Expected {
+		return &DigestMismatchError{Expected: v.Expected, Actual: actual}
+	}
+	return nil
+}
+
+// ValidateWithDigest validates r against mt's schema and verifies that r's
+// content hashes to expected, in a single streaming pass.
+func (mt MediaType) ValidateWithDigest(r io.Reader, expected digest.Digest) error {
+	v, err := NewVerifyingValidator(mt, expected)
+	if err != nil {
+		return err
+	}
+	return v.Validate(r)
+}
@@ -0,0 +1,71 @@
+// Copyright 2016 The Linux Foundation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schema_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	digest "github.com/opencontainers/go-digest"
+
+	"github.com/opencontainers/image-spec/schema"
+)
+
+const testManifest = `{
+   "schemaVersion": 2,
+   "mediaType": "application/vnd.docker.distribution.manifest.v2+json",
+   "config": {
+      "mediaType": "application/octet-stream",
+      "size": 3210,
+      "digest": "sha256:5359a4f250650c20227055957e353e8f8a74152f35fe36f00b6b1f9fc19c8861"
+   },
+   "layers": []
+}`
+
+func testManifestDigest() digest.Digest {
+	sum := sha256.Sum256([]byte(testManifest))
+	return digest.Digest(fmt.Sprintf("sha256:%s", hex.EncodeToString(sum[:])))
+}
+
+func TestValidateWithDigestSuccess(t *testing.T) {
+	if err := schema.MediaTypeManifest.ValidateWithDigest(strings.NewReader(testManifest), testManifestDigest()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestValidateWithDigestMismatch(t *testing.T) {
+	wrong := digest.Digest("sha256:0000000000000000000000000000000000000000000000000000000000000000")
+
+	err := schema.MediaTypeManifest.ValidateWithDigest(strings.NewReader(testManifest), wrong)
+	if err == nil {
+		t.Fatal("expected a digest mismatch error, got nil")
+	}
+
+	var mismatch *schema.DigestMismatchError
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("expected a *schema.DigestMismatchError, got %T: %v", err, err)
+	}
+}
+
+func TestValidateWithDigestRejectsUnsupportedAlgorithm(t *testing.T) {
+	_, err := schema.NewVerifyingValidator(schema.MediaTypeManifest, digest.Digest("md5:d41d8cd98f00b204e9800998ecf8427e"))
+	if err == nil {
+		t.Fatal("expected an error for an unsupported digest algorithm, got nil")
+	}
+}